@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"gitlab.com/gitlab-org/rust/knowledge-graph/bindings/go/internal/libdownload"
+)
+
+// runVerify re-checks an already-installed library against the lockfile
+// without redownloading anything.
+func runVerify(args []string) error {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("getting working directory: %w", err)
+	}
+
+	targetDir := filepath.Join(workDir, "lib")
+	if len(args) > 0 {
+		targetDir = args[0]
+	}
+
+	targetFile, err := filepath.Abs(filepath.Join(targetDir, "libindexer_c_bindings.a"))
+	if err != nil {
+		return fmt.Errorf("getting target location: %w", err)
+	}
+
+	lockfile, err := libdownload.Parse(lockfileData)
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+
+	platform := runtime.GOOS + "-" + runtime.GOARCH
+	entry, err := lockfile.Platform(platform)
+	if err != nil {
+		return fmt.Errorf("resolving lockfile entry: %w", err)
+	}
+
+	if err := verifyExtractedDigests(targetFile, entry); err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	slog.Info("Installed library matches lockfile", "path", targetFile, "platform", platform)
+	return nil
+}
+
+// runLock implements the "lock update" helper: fetch the artifact for the
+// current Version, compute its digest and the digest of the file it
+// gunzips to (the one downloadAndExtract and the verify subcommand pin in
+// ExtractedDigests), and rewrite libindexer.lock. It is a maintainer-facing
+// tool, run from the repo checkout rather than CI.
+func runLock(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "update" {
+		return fmt.Errorf("usage: lock update")
+	}
+
+	_, thisFile, _, ok := runtime.Caller(0)
+	if !ok {
+		return fmt.Errorf("resolving source location")
+	}
+	lockPath := filepath.Join(filepath.Dir(thisFile), "libindexer.lock")
+
+	lockfile, err := libdownload.Load(lockPath)
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
+	}
+
+	for platform, entry := range lockfile.Platforms {
+		url := fmt.Sprintf("https://gitlab.com/api/v4/projects/69095239/packages/generic/release/%s/libindexer_c_bindings-%s.a.gz", Version, platform)
+
+		archive, size, digest, err := fetchArchive(ctx, url, libdownload.SHA256)
+		if err != nil {
+			return fmt.Errorf("updating lock entry for %s: %w", platform, err)
+		}
+
+		entry.Mirrors = []string{url}
+		entry.Size = size
+		entry.Algorithm = libdownload.SHA256
+		entry.Digest = digest
+
+		extractedDigest, err := hashGzippedFile(archive, libdownload.SHA256)
+		if err != nil {
+			return fmt.Errorf("hashing extracted library for %s: %w", platform, err)
+		}
+		for i := range entry.ExtractedDigests {
+			entry.ExtractedDigests[i].Algorithm = libdownload.SHA256
+			entry.ExtractedDigests[i].Digest = extractedDigest
+		}
+		lockfile.Platforms[platform] = entry
+
+		slog.Info("Updated lockfile entry", "platform", platform, "size", size, "digest", digest, "extracted_digest", extractedDigest)
+	}
+
+	lockfile.Version = Version
+	if err := lockfile.Save(lockPath); err != nil {
+		return fmt.Errorf("saving lockfile: %w", err)
+	}
+
+	slog.Info("Lockfile updated", "path", lockPath, "version", Version)
+	return nil
+}
+
+// fetchArchive downloads url in full, returning its raw (still-gzipped)
+// bytes alongside their size and digest, so the caller can both record the
+// archive-level digest and decompress the same bytes to hash what's inside.
+func fetchArchive(ctx context.Context, url string, alg libdownload.Algorithm) ([]byte, int64, string, error) {
+	client := &http.Client{Timeout: 5 * time.Minute}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("creating request: %w", err)
+	}
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("reading %s: %w", url, err)
+	}
+
+	hashingBody, err := libdownload.NewHashingReader(bytes.NewReader(data), alg)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	size, err := io.Copy(io.Discard, hashingBody)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("hashing %s: %w", url, err)
+	}
+
+	return data, size, hashingBody.Sum(), nil
+}
+
+// hashGzippedFile decompresses archive (the raw .a.gz bytes) and hashes
+// what comes out, since that's what downloadAndExtract installs and what
+// ExtractedDigests pins.
+func hashGzippedFile(archive []byte, alg libdownload.Algorithm) (string, error) {
+	gzr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return "", fmt.Errorf("creating gzip reader: %w", err)
+	}
+	defer gzr.Close()
+
+	hr, err := libdownload.NewHashingReader(gzr, alg)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(io.Discard, hr); err != nil {
+		return "", fmt.Errorf("hashing decompressed archive: %w", err)
+	}
+
+	return hr.Sum(), nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"compress/gzip"
 	"context"
+	_ "embed"
 	"fmt"
 	"io"
 	"log/slog"
@@ -11,11 +12,16 @@ import (
 	"path/filepath"
 	"runtime"
 	"time"
+
+	"gitlab.com/gitlab-org/rust/knowledge-graph/bindings/go/internal/libdownload"
 )
 
 // version is auto-updated by scripts/semantic-release-prepare.sh
 const Version = "0.9.0"
 
+//go:embed libindexer.lock
+var lockfileData []byte
+
 func main() {
 	// Initialize structured logger
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
@@ -23,21 +29,36 @@ func main() {
 	}))
 	slog.SetDefault(logger)
 
-	if err := run(); err != nil {
+	if err := dispatch(context.Background(), os.Args[1:]); err != nil {
 		slog.Error("Application failed", "error", err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
+// dispatch routes to the "verify" and "lock" subcommands, falling back to
+// the original behavior (plain download) so existing callers that only
+// ever pass a target directory keep working unmodified.
+func dispatch(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "verify":
+			return runVerify(args[1:])
+		case "lock":
+			return runLock(ctx, args[1:])
+		}
+	}
+	return run(ctx, args)
+}
+
+func run(ctx context.Context, args []string) error {
 	workDir, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("getting working directory: %w", err)
 	}
 
 	targetDir := filepath.Join(workDir, "lib")
-	if len(os.Args) > 1 {
-		targetDir = os.Args[1]
+	if len(args) > 0 {
+		targetDir = args[0]
 	}
 
 	targetFile, err := filepath.Abs(filepath.Join(targetDir, "libindexer_c_bindings.a"))
@@ -45,87 +66,78 @@ func run() error {
 		return fmt.Errorf("getting target location: %w", err)
 	}
 
-	if _, err := os.Stat(targetFile); err == nil {
-		slog.Info("File already exists, skipping download", "path", targetFile)
-		return nil
+	lockfile, err := libdownload.Parse(lockfileData)
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
 	}
 
-	// when the repository is public, we can switch to
-	// https://gitlab.com/gitlab-org/rust/knowledge-graph/-/releases URL
-	// for now REST API is used to authenticate with GITLAB_TOKEN
-	projectId := "69095239" // https://gitlab.com/gitlab-org/rust/knowledge-graph
 	platform := runtime.GOOS + "-" + runtime.GOARCH
-	url := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/packages/generic/release/%s/libindexer_c_bindings-%s.a.gz", projectId, Version, platform)
-
-	slog.Info("Starting download",
-		"version", Version,
-		"platform", platform,
-		"target", targetFile)
-
-	if err := downloadAndExtract(url, targetFile); err != nil {
-		return fmt.Errorf("downloading and extracting library: %w", err)
-	}
-
-	slog.Info("Successfully downloaded and extracted library", "path", targetFile)
-	return nil
-}
-
-func downloadAndExtract(url string, targetFile string) error {
-	tmpFile, err := os.CreateTemp("", "libindexer_c_bindings-*.gz")
+	entry, err := lockfile.Platform(platform)
 	if err != nil {
-		return fmt.Errorf("creating temporary file: %w", err)
+		return fmt.Errorf("resolving download source: %w", err)
 	}
-	defer func() {
-		tmpFile.Close()
-		if err := os.Remove(tmpFile.Name()); err != nil {
-			slog.Warn("Failed to remove temporary file", "file", tmpFile.Name(), "error", err)
-		}
-	}()
 
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 5 * time.Minute,
+	if _, err := os.Stat(targetFile); err == nil {
+		if verifyErr := verifyExtractedDigests(targetFile, entry); verifyErr == nil {
+			slog.Info("File already exists and matches lockfile, skipping download", "path", targetFile)
+			return nil
+		} else {
+			slog.Warn("Existing file failed verification, re-downloading", "path", targetFile, "error", verifyErr)
+		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	transport, err := libdownload.NewTransport()
 	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+		return fmt.Errorf("building HTTP transport: %w", err)
 	}
+	client := &http.Client{Transport: transport}
 
-	// Use GITLAB_TOKEN instead of TOKEN
-	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
-		req.Header.Set("PRIVATE-TOKEN", token)
-		slog.Debug("Using GitLab token for authentication")
-	} else {
+	token := os.Getenv("GITLAB_TOKEN")
+	if token == "" {
 		slog.Warn("No GITLAB_TOKEN found, proceeding without authentication")
 	}
 
-	slog.Info("Downloading file", "url", url)
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("downloading file: %w", err)
+	mirrors := resolveMirrors(ctx, client, lockfile.Version, platform, token, entry.Mirrors)
+	if len(mirrors) == 0 {
+		return fmt.Errorf("no source resolved a download URL for %s %s", lockfile.Version, platform)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
-	}
+	slog.Info("Starting download",
+		"version", lockfile.Version,
+		"platform", platform,
+		"target", targetFile,
+		"mirrors", len(mirrors))
 
-	// Log content length if available
-	if resp.ContentLength > 0 {
-		slog.Info("Download started", "size_bytes", resp.ContentLength)
+	if err := downloadAndExtract(ctx, client, lockfile.Version, platform, targetFile, mirrors, token, entry); err != nil {
+		return fmt.Errorf("downloading and extracting library: %w", err)
 	}
 
-	if _, err = io.Copy(tmpFile, resp.Body); err != nil {
-		return fmt.Errorf("writing to temporary file: %w", err)
+	slog.Info("Successfully downloaded and extracted library", "path", targetFile)
+	return nil
+}
+
+func downloadAndExtract(ctx context.Context, client *http.Client, version, platform, targetFile string, mirrors []string, token string, entry libdownload.PlatformEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
+
+	result, err := libdownload.Fetch(ctx, client, libdownload.FetchOptions{
+		Mirrors:        mirrors,
+		StagingDir:     stagingDir(),
+		CacheKey:       fmt.Sprintf("%s-%s-%s", version, platform, entry.Digest),
+		Token:          token,
+		ExpectedSize:   entry.Size,
+		Algorithm:      entry.Algorithm,
+		ExpectedDigest: entry.Digest,
+	})
+	if err != nil {
+		return fmt.Errorf("fetching archive: %w", err)
 	}
 
-	if _, err = tmpFile.Seek(0, io.SeekStart); err != nil {
-		return fmt.Errorf("seeking temporary file: %w", err)
+	staged, err := os.Open(result.Path)
+	if err != nil {
+		return fmt.Errorf("opening staged archive: %w", err)
 	}
+	defer staged.Close()
 
 	// Ensure target directory exists
 	targetDir := filepath.Dir(targetFile)
@@ -133,22 +145,69 @@ func downloadAndExtract(url string, targetFile string) error {
 		return fmt.Errorf("creating target directory %s: %w", targetDir, err)
 	}
 
-	out, err := os.Create(targetFile)
+	// Extract into a temp file next to targetFile and only rename it into
+	// place once ExtractedDigests has verified it, so a bad extraction (or
+	// a crash mid-write) never leaves a corrupt file that a later "already
+	// exists" check would otherwise trust.
+	tmpFile, err := os.CreateTemp(targetDir, ".libindexer_c_bindings-*.a.tmp")
 	if err != nil {
-		return fmt.Errorf("creating target file %s: %w", targetFile, err)
+		return fmt.Errorf("creating temp file in %s: %w", targetDir, err)
 	}
-	defer out.Close()
+	tmpPath := tmpFile.Name()
+	defer func() {
+		tmpFile.Close()
+		if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove temp file", "file", tmpPath, "error", err)
+		}
+	}()
 
-	gzr, err := gzip.NewReader(tmpFile)
+	gzr, err := gzip.NewReader(staged)
 	if err != nil {
 		return fmt.Errorf("creating gzip reader: %w", err)
 	}
 	defer gzr.Close()
 
 	slog.Info("Extracting file", "target", targetFile)
-	if _, err = io.Copy(out, gzr); err != nil {
+	if _, err = io.Copy(tmpFile, gzr); err != nil {
 		return fmt.Errorf("extracting content: %w", err)
 	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("closing temp file %s: %w", tmpPath, err)
+	}
 
+	if err := verifyExtractedDigests(tmpPath, entry); err != nil {
+		return fmt.Errorf("extracted library failed integrity check: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, targetFile); err != nil {
+		return fmt.Errorf("installing extracted library at %s: %w", targetFile, err)
+	}
+
+	if err := os.Remove(result.Path); err != nil {
+		slog.Warn("Failed to remove staged archive", "file", result.Path, "error", err)
+	}
+
+	return nil
+}
+
+// verifyExtractedDigests re-hashes path and compares it against every
+// ExtractedDigests entry for "libindexer_c_bindings.a", the one file this
+// binary ever extracts. Used both right after extraction and to decide
+// whether an already-present file can be trusted instead of re-downloaded.
+func verifyExtractedDigests(path string, entry libdownload.PlatformEntry) error {
+	for _, fd := range entry.ExtractedDigests {
+		if fd.Path != "libindexer_c_bindings.a" {
+			continue
+		}
+		if err := libdownload.VerifyExtractedFile(path, fd); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// stagingDir holds partial downloads so an interrupted fetch can resume
+// instead of starting over.
+func stagingDir() string {
+	return filepath.Join(os.TempDir(), "libindexer-download-cache")
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const gitlabProjectID = "69095239" // https://gitlab.com/gitlab-org/rust/knowledge-graph
+
+// Source resolves a downloadable URL for an archive, given the release
+// version and platform (e.g. "linux-amd64"). Different sources exist
+// because not every caller can or wants to hit the authenticated GitLab
+// Packages API: once the project is public the Releases API needs no
+// token, and self-hosted/air-gapped users may want their own mirror.
+type Source interface {
+	Name() string
+	Resolve(ctx context.Context, client *http.Client, version, platform string) (string, error)
+}
+
+// GitLabPackages is the original behavior: the GitLab Packages REST API,
+// authenticated with GITLAB_TOKEN.
+type GitLabPackages struct {
+	ProjectID string
+	Token     string
+}
+
+func (s GitLabPackages) Name() string { return "packages" }
+
+func (s GitLabPackages) Resolve(_ context.Context, _ *http.Client, version, platform string) (string, error) {
+	return fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/packages/generic/release/%s/libindexer_c_bindings-%s.a.gz", s.ProjectID, version, platform), nil
+}
+
+// GitLabReleaseAssets resolves the asset URL attached to a GitLab release,
+// for use once the upstream repository is public and Packages API auth is
+// no longer required.
+type GitLabReleaseAssets struct {
+	ProjectID string
+	Token     string
+
+	// baseURL overrides the GitLab instance for tests; empty means
+	// https://gitlab.com.
+	baseURL string
+}
+
+func (s GitLabReleaseAssets) Name() string { return "releases" }
+
+type gitlabRelease struct {
+	Assets struct {
+		Links []struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"links"`
+	} `json:"assets"`
+}
+
+func (s GitLabReleaseAssets) Resolve(ctx context.Context, client *http.Client, version, platform string) (string, error) {
+	base := s.baseURL
+	if base == "" {
+		base = "https://gitlab.com"
+	}
+	releaseURL := fmt.Sprintf("%s/api/v4/projects/%s/releases/%s", base, s.ProjectID, version)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", releaseURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	if s.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", s.Token)
+	}
+
+	resp, err := doWithRateLimitBackoff(client, req)
+	if err != nil {
+		return "", fmt.Errorf("fetching release %s: %w", version, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching release %s: HTTP %d", version, resp.StatusCode)
+	}
+
+	var release gitlabRelease
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("parsing release %s: %w", version, err)
+	}
+
+	for _, link := range release.Assets.Links {
+		if strings.Contains(link.Name, platform) {
+			return link.URL, nil
+		}
+	}
+
+	return "", fmt.Errorf("release %s has no asset matching platform %q", version, platform)
+}
+
+// doWithRateLimitBackoff retries once if GitLab's rate-limit headers say
+// we're out of budget, sleeping for Retry-After seconds (or 5 seconds if
+// Retry-After is absent or not an integer) before trying again.
+func doWithRateLimitBackoff(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	limited := resp.StatusCode == http.StatusTooManyRequests || resp.Header.Get("RateLimit-Remaining") == "0"
+	if !limited {
+		return resp, nil
+	}
+
+	wait := 5 * time.Second
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			wait = time.Duration(seconds) * time.Second
+		}
+	}
+	resp.Body.Close()
+
+	slog.Warn("Rate limited, backing off before retrying", "wait", wait)
+	time.Sleep(wait)
+
+	return client.Do(req.Clone(req.Context()))
+}
+
+// GenericHTTP resolves a URL from a template containing {version} and
+// {platform} placeholders, for self-hosted mirrors or an S3 cache.
+type GenericHTTP struct {
+	Template string
+}
+
+func (s GenericHTTP) Name() string { return "generic:" + s.Template }
+
+func (s GenericHTTP) Resolve(_ context.Context, _ *http.Client, version, platform string) (string, error) {
+	r := strings.NewReplacer("{version}", version, "{platform}", platform)
+	return r.Replace(s.Template), nil
+}
+
+// parseSource turns one LIBINDEXER_SOURCE entry ("packages", "releases", or
+// an arbitrary https:// template) into a Source.
+func parseSource(spec, token string) (Source, error) {
+	switch spec {
+	case "packages":
+		return GitLabPackages{ProjectID: gitlabProjectID, Token: token}, nil
+	case "releases":
+		return GitLabReleaseAssets{ProjectID: gitlabProjectID, Token: token}, nil
+	default:
+		if strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") {
+			return GenericHTTP{Template: spec}, nil
+		}
+		return nil, fmt.Errorf("unknown source %q", spec)
+	}
+}
+
+// resolveMirrors builds the list of candidate URLs to try, in order: each
+// configured source (LIBINDEXER_SOURCE, defaulting to "packages,releases"),
+// then any mirrors already pinned in the lockfile as a last-resort fallback.
+func resolveMirrors(ctx context.Context, client *http.Client, version, platform, token string, lockfileMirrors []string) []string {
+	specs := strings.Split(envOr("LIBINDEXER_SOURCE", "packages,releases"), ",")
+
+	var mirrors []string
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		source, err := parseSource(spec, token)
+		if err != nil {
+			slog.Warn("Skipping unknown source", "source", spec, "error", err)
+			continue
+		}
+
+		url, err := source.Resolve(ctx, client, version, platform)
+		if err != nil {
+			slog.Warn("Source failed to resolve a download URL", "source", source.Name(), "error", err)
+			continue
+		}
+
+		slog.Info("Resolved download source", "source", source.Name(), "url", url)
+		mirrors = append(mirrors, url)
+	}
+
+	return append(mirrors, lockfileMirrors...)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitLabReleaseAssetsResolvesMatchingAsset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("PRIVATE-TOKEN"); got != "secret" {
+			t.Errorf("PRIVATE-TOKEN header = %q, want %q", got, "secret")
+		}
+		w.Write([]byte(`{"assets":{"links":[
+			{"name":"libindexer_c_bindings-darwin-arm64.a.gz","url":"https://example.com/darwin-arm64.a.gz"},
+			{"name":"libindexer_c_bindings-linux-amd64.a.gz","url":"https://example.com/linux-amd64.a.gz"}
+		]}}`))
+	}))
+	defer server.Close()
+
+	s := GitLabReleaseAssets{ProjectID: "1", Token: "secret", baseURL: server.URL}
+	url, err := s.Resolve(context.Background(), server.Client(), "v1.0.0", "linux-amd64")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if want := "https://example.com/linux-amd64.a.gz"; url != want {
+		t.Errorf("Resolve() = %q, want %q", url, want)
+	}
+}
+
+func TestGitLabReleaseAssetsNoMatchingAsset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"assets":{"links":[{"name":"libindexer_c_bindings-darwin-arm64.a.gz","url":"https://example.com/darwin-arm64.a.gz"}]}}`))
+	}))
+	defer server.Close()
+
+	s := GitLabReleaseAssets{ProjectID: "1", baseURL: server.URL}
+	if _, err := s.Resolve(context.Background(), server.Client(), "v1.0.0", "linux-amd64"); err == nil {
+		t.Fatal("Resolve() error = nil, want an error for no matching asset")
+	}
+}
+
+func TestDoWithRateLimitBackoffRetriesAfterRetryAfter(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	resp, err := doWithRateLimitBackoff(server.Client(), req)
+	if err != nil {
+		t.Fatalf("doWithRateLimitBackoff() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("final status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestGenericHTTPSubstitutesTemplate(t *testing.T) {
+	s := GenericHTTP{Template: "https://mirror.example/{version}/libindexer-{platform}.a.gz"}
+	url, err := s.Resolve(context.Background(), nil, "v1.2.3", "linux-amd64")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if want := "https://mirror.example/v1.2.3/libindexer-linux-amd64.a.gz"; url != want {
+		t.Errorf("Resolve() = %q, want %q", url, want)
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"packages", false},
+		{"releases", false},
+		{"https://mirror.example/{version}/{platform}.a.gz", false},
+		{"not-a-source", true},
+	}
+
+	for _, tt := range tests {
+		_, err := parseSource(tt.spec, "token")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSource(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"gitlab.com/gitlab-org/rust/knowledge-graph/bindings/go/internal/libdownload"
+)
+
+// wantedChunkedEntry reports whether a zstd:chunked TOC entry is part of
+// what the indexer actually links against: the static library itself and
+// any headers under include/. Debug symbols, docs, and other platforms'
+// leftovers are skipped without ever being downloaded.
+func wantedChunkedEntry(name string) bool {
+	return name == "lib/libindexer_c_bindings.a" || strings.HasPrefix(name, "include/")
+}
+
+// downloadChunked fetches only the TOC and the entries wantedChunkedEntry
+// selects, each via its own HTTP Range request, instead of downloading the
+// whole archive. Like the plain path, it tries mirrors in order and retries
+// transient failures (a network error or 5xx on the HEAD or Range request)
+// with backoff before giving up on that mirror; a mirror that answers a
+// Range request with anything other than 206 or 5xx is given up on at once.
+func downloadChunked(ctx context.Context, client *http.Client, mirrors []string, token string, root *os.Root, entry libdownload.PlatformEntry) error {
+	if len(mirrors) == 0 {
+		return fmt.Errorf("no mirrors to fetch from")
+	}
+
+	var lastErr error
+	for _, url := range mirrors {
+		err := libdownload.WithBackoff(ctx, 3, time.Second, func(attempt int) error {
+			return downloadChunkedFromMirror(ctx, client, url, token, root, entry)
+		})
+		if err == nil {
+			return nil
+		}
+		lastErr = fmt.Errorf("mirror %s: %w", url, err)
+		slog.Warn("Mirror failed for zstd:chunked download, trying next", "url", url, "error", err)
+	}
+
+	return fmt.Errorf("all mirrors failed: %w", lastErr)
+}
+
+func downloadChunkedFromMirror(ctx context.Context, client *http.Client, url, token string, root *os.Root, entry libdownload.PlatformEntry) error {
+	size, err := headContentLength(ctx, client, url, token)
+	if err != nil {
+		return fmt.Errorf("resolving archive size: %w", err)
+	}
+
+	fetcher := &rangeReader{client: client, url: url, token: token}
+
+	t, err := fetchTOC(ctx, fetcher, size)
+	if err != nil {
+		return fmt.Errorf("reading zstd:chunked TOC: %w", err)
+	}
+
+	digestsByPath := make(map[string]libdownload.FileDigest, len(entry.ExtractedDigests))
+	for _, fd := range entry.ExtractedDigests {
+		digestsByPath[fd.Path] = fd
+	}
+
+	fetchedLib := false
+	for idx, e := range t.Entries {
+		if !wantedChunkedEntry(e.Name) {
+			slog.Debug("Skipping chunk not needed for linking", "name", e.Name)
+			continue
+		}
+
+		if err := fetchChunkedEntry(ctx, fetcher, e, root, digestsByPath[e.Name], idx); err != nil {
+			return fmt.Errorf("fetching chunk %s: %w", e.Name, err)
+		}
+		if e.Name == "lib/libindexer_c_bindings.a" {
+			fetchedLib = true
+		}
+	}
+
+	if !fetchedLib {
+		return fmt.Errorf("TOC did not list lib/libindexer_c_bindings.a, the file needed for linking")
+	}
+
+	slog.Info("Fetched archive entries via Range requests", "of", len(t.Entries))
+	return nil
+}
+
+// fetchChunkedEntry fetches and decompresses a single chunked entry, staging
+// it under a temporary name and renaming it into place only once its digest
+// (when pinned) has verified, so a mismatch never leaves a corrupt or
+// partial file behind for a later run to trust.
+func fetchChunkedEntry(ctx context.Context, fetcher ChunkFetcher, e tocEntry, root *os.Root, fd libdownload.FileDigest, idx int) (retErr error) {
+	rc, err := fetcher.Fetch(ctx, e.Offset, e.CompressedSize)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	zr, err := zstd.NewReader(rc)
+	if err != nil {
+		return fmt.Errorf("creating zstd reader: %w", err)
+	}
+	defer zr.Close()
+
+	if dir := path.Dir(e.Name); dir != "." {
+		if err := mkdirAllInRoot(root, dir); err != nil {
+			return err
+		}
+	}
+
+	tempName := fmt.Sprintf("%s.tmp-%d", e.Name, idx)
+	outFile, err := root.Create(tempName)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", e.Name, err)
+	}
+	defer func() {
+		if retErr != nil {
+			if err := root.Remove(tempName); err != nil && !os.IsNotExist(err) {
+				slog.Warn("Failed to remove staged temp file", "file", tempName, "error", err)
+			}
+		}
+	}()
+
+	var hashReader io.Reader = zr
+	var hr *libdownload.HashingReader
+	if fd.Digest != "" {
+		hr, err = libdownload.NewHashingReader(zr, fd.Algorithm)
+		if err != nil {
+			outFile.Close()
+			return fmt.Errorf("preparing digest verification for %s: %w", e.Name, err)
+		}
+		hashReader = hr
+	}
+
+	if _, err := io.Copy(outFile, hashReader); err != nil {
+		outFile.Close()
+		return fmt.Errorf("extracting %s: %w", e.Name, err)
+	}
+	if err := outFile.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", e.Name, err)
+	}
+
+	if hr != nil && hr.Sum() != fd.Digest {
+		return fmt.Errorf("digest mismatch for %s: want %s:%s, got %s:%s", e.Name, fd.Algorithm, fd.Digest, fd.Algorithm, hr.Sum())
+	}
+
+	if err := installInRoot(root, tempName, e.Name); err != nil {
+		return fmt.Errorf("installing %s: %w", e.Name, err)
+	}
+
+	return nil
+}
+
+// mkdirAllInRoot recreates os.MkdirAll's behavior scoped to an *os.Root,
+// which only exposes single-level Mkdir.
+func mkdirAllInRoot(root *os.Root, dir string) error {
+	var built string
+	for _, segment := range strings.Split(dir, "/") {
+		if segment == "" {
+			continue
+		}
+		built = path.Join(built, segment)
+		if err := root.Mkdir(built, 0755); err != nil && !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("mkdir %q: %w", built, err)
+		}
+	}
+	return nil
+}
+
+func headContentLength(ctx context.Context, client *http.Client, url, token string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating HEAD request: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, libdownload.Retryable(fmt.Errorf("HEAD %s: %w", url, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode >= 500 {
+			return 0, libdownload.Retryable(fmt.Errorf("HEAD %s: HTTP %d", url, resp.StatusCode))
+		}
+		return 0, fmt.Errorf("HEAD %s: HTTP %d", url, resp.StatusCode)
+	}
+	if resp.ContentLength <= 0 {
+		return 0, fmt.Errorf("HEAD %s did not return a content length", url)
+	}
+
+	return resp.ContentLength, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// CompressionFormat identifies how the downloaded archive is compressed.
+type CompressionFormat int
+
+const (
+	FormatGzip CompressionFormat = iota
+	FormatZstd
+	// FormatZstdChunked is a zstd-compressed tar with an appended TOC footer
+	// (see toc.go), allowing individual entries to be fetched and
+	// decompressed independently via HTTP Range requests, in the spirit of
+	// zstd:chunked / estargz lazy pulling.
+	FormatZstdChunked
+)
+
+func (f CompressionFormat) String() string {
+	switch f {
+	case FormatGzip:
+		return "gzip"
+	case FormatZstd:
+		return "zstd"
+	case FormatZstdChunked:
+		return "zstd:chunked"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	gzipMagic = [2]byte{0x1f, 0x8b}
+	zstdMagic = [4]byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// detectCompressionFormatFromURL infers the format from the archive's file
+// extension. It returns ok=false when the URL doesn't carry enough
+// information, in which case the caller should fall back to sniffing magic
+// bytes via detectCompressionFormatFromMagic.
+func detectCompressionFormatFromURL(url string) (CompressionFormat, bool) {
+	switch {
+	case strings.HasSuffix(url, ".chunked.tar.zst"):
+		return FormatZstdChunked, true
+	case strings.HasSuffix(url, ".tar.zst"):
+		return FormatZstd, true
+	case strings.HasSuffix(url, ".tar.gz"), strings.HasSuffix(url, ".tgz"):
+		return FormatGzip, true
+	default:
+		return 0, false
+	}
+}
+
+// detectCompressionFormatFromMagic peeks the first bytes of r without
+// consuming them, for mirrors that don't encode the compression in the URL.
+// It can only ever return FormatGzip or FormatZstd: zstd:chunked is
+// indistinguishable from plain zstd by magic bytes alone and is only
+// selected via the URL (a .chunked.tar.zst suffix).
+func detectCompressionFormatFromMagic(r *bufio.Reader) (CompressionFormat, error) {
+	head, err := r.Peek(4)
+	if err != nil && len(head) == 0 {
+		return 0, fmt.Errorf("peeking archive header: %w", err)
+	}
+
+	if len(head) >= 2 && head[0] == gzipMagic[0] && head[1] == gzipMagic[1] {
+		return FormatGzip, nil
+	}
+	if len(head) >= 4 && head[0] == zstdMagic[0] && head[1] == zstdMagic[1] && head[2] == zstdMagic[2] && head[3] == zstdMagic[3] {
+		return FormatZstd, nil
+	}
+
+	return 0, fmt.Errorf("unrecognized archive magic bytes: % x", head)
+}
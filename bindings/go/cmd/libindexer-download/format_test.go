@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestDetectCompressionFormatFromURL(t *testing.T) {
+	tests := []struct {
+		url    string
+		want   CompressionFormat
+		wantOk bool
+	}{
+		{"https://example.com/lib.chunked.tar.zst", FormatZstdChunked, true},
+		{"https://example.com/lib.tar.zst", FormatZstd, true},
+		{"https://example.com/lib.tar.gz", FormatGzip, true},
+		{"https://example.com/lib.tgz", FormatGzip, true},
+		{"https://example.com/lib.a.gz", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := detectCompressionFormatFromURL(tt.url)
+		if ok != tt.wantOk || (ok && got != tt.want) {
+			t.Errorf("detectCompressionFormatFromURL(%q) = (%v, %v), want (%v, %v)", tt.url, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestDetectCompressionFormatFromMagic(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  []byte
+		want    CompressionFormat
+		wantErr bool
+	}{
+		{"gzip", []byte{0x1f, 0x8b, 0x08, 0x00}, FormatGzip, false},
+		{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}, FormatZstd, false},
+		{"unrecognized", []byte{0x00, 0x01, 0x02, 0x03}, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := detectCompressionFormatFromMagic(bufio.NewReader(bytes.NewReader(tt.header)))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: detectCompressionFormatFromMagic() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%s: detectCompressionFormatFromMagic() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
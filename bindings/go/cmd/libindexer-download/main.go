@@ -2,8 +2,10 @@ package main
 
 import (
 	"archive/tar"
+	"bufio"
 	"compress/gzip"
 	"context"
+	_ "embed"
 	"errors"
 	"fmt"
 	"io"
@@ -15,11 +17,18 @@ import (
 	"path/filepath"
 	"runtime"
 	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"gitlab.com/gitlab-org/rust/knowledge-graph/bindings/go/internal/libdownload"
 )
 
 // version is auto-updated by scripts/semantic-release-prepare.sh
 const Version = "0.14.0"
 
+//go:embed libindexer.lock
+var lockfileData []byte
+
 func main() {
 	// Initialize structured logger
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
@@ -29,21 +38,36 @@ func main() {
 
 	ctx := context.Background()
 
-	if err := run(ctx); err != nil {
+	if err := dispatch(ctx, os.Args[1:]); err != nil {
 		slog.Error("Application failed", "error", err)
 		os.Exit(1)
 	}
 }
 
-func run(ctx context.Context) error {
+// dispatch routes to the "verify" and "lock" subcommands, falling back to
+// the original behavior (plain download) so existing callers that only
+// ever pass a target directory keep working unmodified.
+func dispatch(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "verify":
+			return runVerify(args[1:])
+		case "lock":
+			return runLock(ctx, args[1:])
+		}
+	}
+	return run(ctx, args)
+}
+
+func run(ctx context.Context, args []string) error {
 	workDir, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("getting working directory: %w", err)
 	}
 
 	targetDir := filepath.Join(workDir, "libindexer")
-	if len(os.Args) > 1 {
-		targetDir = os.Args[1]
+	if len(args) > 0 {
+		targetDir = args[0]
 	}
 
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
@@ -54,22 +78,37 @@ func run(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("open root: %w", err)
 	}
+	defer root.Close()
 
-	if _, err := root.Stat(path.Join("lib", "libindexer_c_bindings.a")); err == nil {
-		slog.Info("File already exists, skipping download")
-		return nil
+	lockfile, err := libdownload.Parse(lockfileData)
+	if err != nil {
+		return fmt.Errorf("loading lockfile: %w", err)
 	}
 
-	projectId := "69095239" // https://gitlab.com/gitlab-org/rust/knowledge-graph
 	platform := runtime.GOOS + "-" + runtime.GOARCH
-	url := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/packages/generic/release/%s/libindexer_c_bindings-%s.tar.gz", projectId, Version, platform)
+	entry, err := lockfile.Platform(platform)
+	if err != nil {
+		return fmt.Errorf("resolving download source: %w", err)
+	}
+	if len(entry.Mirrors) == 0 {
+		return fmt.Errorf("lockfile entry for %s has no mirrors", platform)
+	}
 
+	if _, statErr := root.Stat(path.Join("lib", "libindexer_c_bindings.a")); statErr == nil {
+		if verifyErr := verifyInstalledEntries(root, entry); verifyErr == nil {
+			slog.Info("File already exists and matches lockfile, skipping download")
+			return nil
+		} else {
+			slog.Warn("Existing install failed verification, re-downloading", "error", verifyErr)
+		}
+	}
 	slog.Info("Starting download",
-		"version", Version,
+		"version", lockfile.Version,
 		"platform", platform,
-		"target", targetDir)
+		"target", targetDir,
+		"mirrors", len(entry.Mirrors))
 
-	if err := downloadAndExtract(ctx, url, root); err != nil {
+	if err := downloadAndExtract(ctx, lockfile.Version, platform, root, entry); err != nil {
 		return fmt.Errorf("downloading and extracting library: %w", err)
 	}
 
@@ -77,53 +116,112 @@ func run(ctx context.Context) error {
 	return nil
 }
 
-func downloadAndExtract(ctx context.Context, url string, root *os.Root) (retErr error) {
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
-	defer cancel()
+func downloadAndExtract(ctx context.Context, version, platform string, root *os.Root, entry libdownload.PlatformEntry) error {
+	transport, err := libdownload.NewTransport()
+	if err != nil {
+		return fmt.Errorf("building HTTP transport: %w", err)
+	}
+	client := &http.Client{Transport: transport}
+	token := os.Getenv("GITLAB_TOKEN")
 
-	client := &http.Client{
-		Timeout: 5 * time.Minute,
+	if format, ok := detectCompressionFormatFromURL(entry.Mirrors[0]); ok && format == FormatZstdChunked {
+		slog.Info("Using zstd:chunked partial extraction", "mirrors", len(entry.Mirrors))
+		return downloadChunked(ctx, client, entry.Mirrors, token, root, entry)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
+
+	result, err := libdownload.Fetch(ctx, client, libdownload.FetchOptions{
+		Mirrors:        entry.Mirrors,
+		StagingDir:     filepath.Join(os.TempDir(), "libindexer-download-cache"),
+		CacheKey:       fmt.Sprintf("%s-%s-%s", version, platform, entry.Digest),
+		Token:          token,
+		ExpectedSize:   entry.Size,
+		Algorithm:      entry.Algorithm,
+		ExpectedDigest: entry.Digest,
+	})
 	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+		return fmt.Errorf("fetching archive: %w", err)
 	}
 
-	slog.Info("Downloading file", "url", url)
-	resp, err := client.Do(req)
+	staged, err := os.Open(result.Path)
 	if err != nil {
-		return fmt.Errorf("downloading file: %w", err)
+		return fmt.Errorf("opening staged archive: %w", err)
 	}
-	defer func() {
-		err := resp.Body.Close()
-		if retErr == nil && err != nil {
-			retErr = fmt.Errorf("closing file: %w", err)
+	defer staged.Close()
+
+	format, ok := detectCompressionFormatFromURL(entry.Mirrors[0])
+	if !ok {
+		format, err = detectCompressionFormatFromMagic(bufio.NewReader(staged))
+		if err != nil {
+			return fmt.Errorf("detecting archive compression: %w", err)
 		}
-	}()
+		if _, err := staged.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("seeking staged archive: %w", err)
+		}
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	if err := extractArchive(staged, root, format, entry); err != nil {
+		return err
 	}
 
-	// Log content length if available
-	if resp.ContentLength > 0 {
-		slog.Info("Download started", "size_bytes", resp.ContentLength)
+	if err := os.Remove(result.Path); err != nil {
+		slog.Warn("Failed to remove staged archive", "file", result.Path, "error", err)
 	}
 
-	gzr, err := gzip.NewReader(resp.Body)
-	if err != nil {
-		return fmt.Errorf("creating gzip reader: %w", err)
+	return nil
+}
+
+// extractedFile records where a tar entry's content was staged (tempName,
+// a sibling of the final name within root) until it's known to be safe to
+// expose at name.
+type extractedFile struct {
+	name, tempName string
+}
+
+// extractArchive untars decompressed into root, staging every regular file
+// under a temporary name first. Nothing is exposed under its real name
+// until every ExtractedDigests entry has been verified against its staged
+// content, so a digest mismatch (or a crash mid-extraction) can never leave
+// a corrupt or partial file where a later run's "already exists" check
+// would trust it.
+func extractArchive(archive io.Reader, root *os.Root, format CompressionFormat, entry libdownload.PlatformEntry) (retErr error) {
+	var decompressed io.Reader
+	switch format {
+	case FormatGzip:
+		gzr, err := gzip.NewReader(archive)
+		if err != nil {
+			return fmt.Errorf("creating gzip reader: %w", err)
+		}
+		defer gzr.Close()
+		decompressed = gzr
+	case FormatZstd:
+		zr, err := zstd.NewReader(archive)
+		if err != nil {
+			return fmt.Errorf("creating zstd reader: %w", err)
+		}
+		defer zr.Close()
+		decompressed = zr
+	default:
+		return fmt.Errorf("unsupported compression format %s for full extraction", format)
 	}
+
+	var staged []extractedFile
 	defer func() {
-		err := gzr.Close()
-		if err != nil && retErr == nil {
-			retErr = fmt.Errorf("close gzip: %w", err)
+		if retErr == nil {
+			return
+		}
+		for _, f := range staged {
+			if err := root.Remove(f.tempName); err != nil && !os.IsNotExist(err) {
+				slog.Warn("Failed to remove staged temp file", "file", f.tempName, "error", err)
+			}
 		}
 	}()
 
-	tarReader := tar.NewReader(gzr)
-	slog.Info("Extracting file")
+	tarReader := tar.NewReader(decompressed)
+	slog.Info("Extracting file", "format", format)
+	idx := 0
 	for {
 		header, err := tarReader.Next()
 		if err == io.EOF {
@@ -133,24 +231,133 @@ func downloadAndExtract(ctx context.Context, url string, root *os.Root) (retErr
 			return fmt.Errorf("reading tar: %w", err)
 		}
 
-		if err := extractTarHeader(tarReader, header, root); err != nil {
+		tempName, err := extractTarHeader(tarReader, header, root, idx)
+		if err != nil {
 			return err
 		}
+		idx++
+		if tempName != "" {
+			staged = append(staged, extractedFile{name: header.Name, tempName: tempName})
+		}
+	}
+
+	stagedByName := make(map[string]string, len(staged))
+	for _, f := range staged {
+		stagedByName[f.name] = f.tempName
+	}
+
+	for _, fd := range entry.ExtractedDigests {
+		tempName, ok := stagedByName[fd.Path]
+		if !ok {
+			return fmt.Errorf("pinned file %s not found in archive", fd.Path)
+		}
+		f, err := root.Open(tempName)
+		if err != nil {
+			return fmt.Errorf("opening staged file %s for verification: %w", fd.Path, err)
+		}
+		verifyErr := verifyExtractedReader(f, fd)
+		f.Close()
+		if verifyErr != nil {
+			return fmt.Errorf("extracted file failed integrity check: %w", verifyErr)
+		}
 	}
+
+	for _, f := range staged {
+		if err := installInRoot(root, f.tempName, f.name); err != nil {
+			return fmt.Errorf("installing extracted file %s: %w", f.name, err)
+		}
+	}
+
 	return nil
 }
 
-func extractTarHeader(tarReader *tar.Reader, header *tar.Header, root *os.Root) (retErr error) {
+// installInRoot copies tempName to name within root and removes tempName,
+// standing in for a rename: *os.Root has no Rename method in this Go
+// version, and both names are already guaranteed to live inside root.
+func installInRoot(root *os.Root, tempName, name string) error {
+	src, err := root.Open(tempName)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", tempName, err)
+	}
+	defer src.Close()
+
+	dst, err := root.Create(name)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", name, err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("copying %s to %s: %w", tempName, name, err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", name, err)
+	}
+
+	if err := root.Remove(tempName); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Failed to remove staged temp file", "file", tempName, "error", err)
+	}
+	return nil
+}
+
+// verifyInstalledEntries re-hashes every ExtractedDigests entry already
+// present in root and compares it against the lockfile, so a previously
+// installed (and possibly corrupted or tampered-with) library is never
+// trusted on presence alone.
+func verifyInstalledEntries(root *os.Root, entry libdownload.PlatformEntry) error {
+	for _, fd := range entry.ExtractedDigests {
+		f, err := root.Open(fd.Path)
+		if err != nil {
+			return fmt.Errorf("opening %s: %w", fd.Path, err)
+		}
+		verifyErr := verifyExtractedReader(f, fd)
+		f.Close()
+		if verifyErr != nil {
+			return verifyErr
+		}
+	}
+	return nil
+}
+
+// verifyExtractedReader hashes an already-open extracted file and compares
+// it against fd, mirroring libdownload.VerifyExtractedFile for callers that
+// only have an *os.File scoped to an os.Root.
+func verifyExtractedReader(r io.Reader, fd libdownload.FileDigest) error {
+	hr, err := libdownload.NewHashingReader(r, fd.Algorithm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, hr); err != nil {
+		return fmt.Errorf("hashing %s: %w", fd.Path, err)
+	}
+	if hr.Sum() != fd.Digest {
+		return fmt.Errorf("digest mismatch for %s: want %s:%s, got %s:%s", fd.Path, fd.Algorithm, fd.Digest, fd.Algorithm, hr.Sum())
+	}
+	return nil
+}
+
+// extractTarHeader extracts a single tar entry into root. Regular files are
+// written under a temporary name (returned as tempName) so the caller can
+// verify and rename them into place once the whole archive has been read;
+// directories are created under their real name immediately since nothing
+// verifies them.
+func extractTarHeader(tarReader *tar.Reader, header *tar.Header, root *os.Root, idx int) (tempName string, retErr error) {
 	switch header.Typeflag {
 	case tar.TypeDir:
 		err := root.Mkdir(header.Name, 0755)
 		if err != nil && !errors.Is(err, fs.ErrExist) {
-			return fmt.Errorf("mkdirall: %q: %w", header.Name, err)
+			return "", fmt.Errorf("mkdirall: %q: %w", header.Name, err)
 		}
 	case tar.TypeReg:
-		outFile, err := root.Create(header.Name)
+		if dir := path.Dir(header.Name); dir != "." {
+			if err := mkdirAllInRoot(root, dir); err != nil {
+				return "", err
+			}
+		}
+
+		tempName = fmt.Sprintf("%s.tmp-%d", header.Name, idx)
+		outFile, err := root.Create(tempName)
 		if err != nil {
-			return fmt.Errorf("create file: %w", err)
+			return "", fmt.Errorf("create file: %w", err)
 		}
 		defer func() {
 			err := outFile.Close()
@@ -160,11 +367,11 @@ func extractTarHeader(tarReader *tar.Reader, header *tar.Header, root *os.Root)
 		}()
 
 		if _, err := io.Copy(outFile, tarReader); err != nil {
-			return fmt.Errorf("copy: %w", err)
+			return "", fmt.Errorf("copy: %w", err)
 		}
 	default:
-		return fmt.Errorf("unknown type: %c in %s", header.Typeflag, header.Name)
+		return "", fmt.Errorf("unknown type: %c in %s", header.Typeflag, header.Name)
 	}
 
-	return nil
+	return tempName, nil
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"gitlab.com/gitlab-org/rust/knowledge-graph/bindings/go/internal/libdownload"
+)
+
+// tocFooterSize is the fixed-size trailer every zstd:chunked archive ends
+// with: an 8-byte magic, followed by the offset and length (as big-endian
+// uint64s) of the JSON TOC blob.
+const tocFooterSize = 8 + 8 + 8
+
+var tocMagic = [8]byte{'Z', 'C', 'H', 'U', 'N', 'K', 'E', 'D'}
+
+// tocEntry describes one file packed into a zstd:chunked archive: an
+// independently decompressible zstd frame starting at Offset in the
+// underlying blob.
+type tocEntry struct {
+	Name             string `json:"name"`
+	Offset           int64  `json:"offset"`
+	CompressedSize   int64  `json:"compressed_size"`
+	UncompressedSize int64  `json:"uncompressed_size"`
+}
+
+type toc struct {
+	Entries []tocEntry `json:"entries"`
+}
+
+// ChunkFetcher fetches a byte range [offset, offset+length) of an archive
+// blob. plainReader serves ranges out of an already-fully-downloaded blob;
+// rangeReader fetches each range over HTTP as needed.
+type ChunkFetcher interface {
+	Fetch(ctx context.Context, offset, length int64) (io.ReadCloser, error)
+}
+
+// plainReader serves a blob that already sits fully on disk (or in memory),
+// so a "fetch" is just a bounded read. The non-chunked path in
+// downloadAndExtract does not use it: extractArchive reads the staged
+// archive directly.
+type plainReader struct {
+	ra io.ReaderAt
+}
+
+func (p *plainReader) Fetch(_ context.Context, offset, length int64) (io.ReadCloser, error) {
+	return io.NopCloser(io.NewSectionReader(p.ra, offset, length)), nil
+}
+
+// rangeReader fetches byte ranges from url with HTTP Range requests,
+// downloading only the chunks the caller actually asks for.
+type rangeReader struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+func (r *rangeReader) Fetch(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", r.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating range request: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	if r.token != "" {
+		req.Header.Set("PRIVATE-TOKEN", r.token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, libdownload.Retryable(fmt.Errorf("fetching range %d-%d: %w", offset, offset+length-1, err))
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		err := fmt.Errorf("range request got HTTP %d, want 206 (mirror may not support Range)", resp.StatusCode)
+		if resp.StatusCode >= 500 {
+			return nil, libdownload.Retryable(err)
+		}
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// fetchTOC reads the footer at the end of a blob of totalSize bytes and
+// parses the TOC it points to.
+func fetchTOC(ctx context.Context, fetcher ChunkFetcher, totalSize int64) (*toc, error) {
+	if totalSize < tocFooterSize {
+		return nil, fmt.Errorf("archive too small (%d bytes) to contain a zstd:chunked footer", totalSize)
+	}
+
+	footerRC, err := fetcher.Fetch(ctx, totalSize-tocFooterSize, tocFooterSize)
+	if err != nil {
+		return nil, fmt.Errorf("fetching TOC footer: %w", err)
+	}
+	defer footerRC.Close()
+
+	footer := make([]byte, tocFooterSize)
+	if _, err := io.ReadFull(footerRC, footer); err != nil {
+		return nil, fmt.Errorf("reading TOC footer: %w", err)
+	}
+
+	var magic [8]byte
+	copy(magic[:], footer[:8])
+	if magic != tocMagic {
+		return nil, fmt.Errorf("bad zstd:chunked footer magic % x", magic)
+	}
+
+	tocOffset := int64(binary.BigEndian.Uint64(footer[8:16]))
+	tocLength := int64(binary.BigEndian.Uint64(footer[16:24]))
+
+	tocRC, err := fetcher.Fetch(ctx, tocOffset, tocLength)
+	if err != nil {
+		return nil, fmt.Errorf("fetching TOC: %w", err)
+	}
+	defer tocRC.Close()
+
+	var t toc
+	if err := json.NewDecoder(tocRC).Decode(&t); err != nil {
+		return nil, fmt.Errorf("parsing TOC: %w", err)
+	}
+
+	return &t, nil
+}
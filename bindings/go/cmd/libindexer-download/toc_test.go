@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// buildChunkedBlob assembles a minimal zstd:chunked blob: the TOC JSON
+// followed by the fixed-size footer pointing back at it, mirroring what
+// fetchTOC expects to find at the end of a real archive.
+func buildChunkedBlob(t *testing.T, entries []tocEntry) []byte {
+	t.Helper()
+
+	tocJSON, err := json.Marshal(toc{Entries: entries})
+	if err != nil {
+		t.Fatalf("marshaling TOC: %v", err)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(tocJSON)
+
+	footer := make([]byte, tocFooterSize)
+	copy(footer[:8], tocMagic[:])
+	binary.BigEndian.PutUint64(footer[8:16], 0)
+	binary.BigEndian.PutUint64(footer[16:24], uint64(len(tocJSON)))
+	buf.Write(footer)
+
+	return buf.Bytes()
+}
+
+func TestFetchTOCRoundTrip(t *testing.T) {
+	want := []tocEntry{
+		{Name: "lib/libindexer_c_bindings.a", Offset: 0, CompressedSize: 123, UncompressedSize: 456},
+		{Name: "include/libindexer.h", Offset: 123, CompressedSize: 78, UncompressedSize: 90},
+	}
+	blob := buildChunkedBlob(t, want)
+	fetcher := &plainReader{ra: bytes.NewReader(blob)}
+
+	got, err := fetchTOC(context.Background(), fetcher, int64(len(blob)))
+	if err != nil {
+		t.Fatalf("fetchTOC() error = %v", err)
+	}
+	if len(got.Entries) != len(want) {
+		t.Fatalf("fetchTOC() got %d entries, want %d", len(got.Entries), len(want))
+	}
+	for i, e := range want {
+		if got.Entries[i] != e {
+			t.Errorf("entry %d = %+v, want %+v", i, got.Entries[i], e)
+		}
+	}
+}
+
+func TestFetchTOCBadMagic(t *testing.T) {
+	blob := buildChunkedBlob(t, nil)
+	copy(blob[len(blob)-tocFooterSize:], bytes.Repeat([]byte{0}, 8))
+	fetcher := &plainReader{ra: bytes.NewReader(blob)}
+
+	if _, err := fetchTOC(context.Background(), fetcher, int64(len(blob))); err == nil {
+		t.Fatal("fetchTOC() error = nil, want an error for bad footer magic")
+	} else if !strings.Contains(err.Error(), "bad zstd:chunked footer magic") {
+		t.Errorf("fetchTOC() error = %v, want footer magic error", err)
+	}
+}
+
+func TestFetchTOCArchiveTooSmall(t *testing.T) {
+	fetcher := &plainReader{ra: bytes.NewReader(make([]byte, 4))}
+
+	if _, err := fetchTOC(context.Background(), fetcher, 4); err == nil {
+		t.Fatal("fetchTOC() error = nil, want an error for a too-small archive")
+	}
+}
@@ -0,0 +1,14 @@
+package indexer
+
+import "fmt"
+
+// IndexError wraps a non-zero result code returned by the C indexer so
+// callers can branch on it with errors.As instead of comparing raw uint16s,
+// the way FullIndex's bare return value forces them to today.
+type IndexError struct {
+	Code uint16
+}
+
+func (e *IndexError) Error() string {
+	return fmt.Sprintf("indexer: operation failed with code %d", e.Code)
+}
@@ -0,0 +1,165 @@
+package indexer
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+#include "c_bindings.h"
+*/
+import "C"
+import (
+	"context"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// Options configures OpenIndex. The zero value is valid and uses a single
+// indexing thread, matching FullIndex's behavior when threadNum is 1.
+type Options struct {
+	ThreadNum uint16
+}
+
+// Stats summarizes the effect of an UpdateFiles call.
+type Stats struct {
+	FilesUpdated int
+	FilesDeleted int
+}
+
+// Index is a handle to an open knowledge graph database. Unlike FullIndex,
+// which re-walks and re-parses an entire repository on every call, an Index
+// stays open across calls so a caller (a language server, an editor plugin)
+// can push incremental file changes as they happen.
+//
+// An Index is safe for concurrent use; UpdateFiles calls are serialized.
+type Index struct {
+	mu     sync.Mutex
+	handle C.uintptr_t
+	closed bool
+
+	// queriesMu guards queries independently of mu: Query holds mu for the
+	// duration of the native call, so Close must be able to reach into
+	// queries (and cancel them) without first taking mu, or an abandoned
+	// query holding mu forever would make Close block forever too.
+	queriesMu sync.Mutex
+	queries   map[int]context.CancelFunc
+	nextQuery int
+}
+
+// OpenIndex opens (creating if necessary) the kuzu database at dbPath,
+// using parquetPath as scratch space for intermediate indexing output.
+func OpenIndex(dbPath, parquetPath string, opts Options) (*Index, error) {
+	cDbPath := C.CString(dbPath)
+	defer C.free(unsafe.Pointer(cDbPath))
+	cParquetPath := C.CString(parquetPath)
+	defer C.free(unsafe.Pointer(cParquetPath))
+
+	threadNum := opts.ThreadNum
+	if threadNum == 0 {
+		threadNum = 1
+	}
+
+	var cErr C.ushort
+	handle := C.open_index(cDbPath, cParquetPath, C.ushort(threadNum), &cErr)
+	if cErr != 0 {
+		return nil, &IndexError{Code: uint16(cErr)}
+	}
+
+	return &Index{handle: handle}, nil
+}
+
+// UpdateFiles re-indexes changed and removes deleted from the open index,
+// without re-walking the rest of the repository.
+func (idx *Index) UpdateFiles(changed, deleted []string) (Stats, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.closed {
+		return Stats{}, fmt.Errorf("indexer: index is closed")
+	}
+
+	cChanged, freeChanged := newCStringArray(changed)
+	defer freeChanged()
+	cDeleted, freeDeleted := newCStringArray(deleted)
+	defer freeDeleted()
+
+	cResult := C.execute_repository_update_indexing(idx.handle, cChanged, C.int(len(changed)), cDeleted, C.int(len(deleted)))
+	if cResult != 0 {
+		return Stats{}, &IndexError{Code: uint16(cResult)}
+	}
+
+	return Stats{FilesUpdated: len(changed), FilesDeleted: len(deleted)}, nil
+}
+
+// Close releases the underlying database handle. It is safe to call more
+// than once.
+//
+// Close force-cancels every Query still in flight before taking mu: a
+// caller that abandons a Query's channel without cancelling its context
+// would otherwise leave that query's goroutine blocked forever inside the
+// native call holding mu, wedging Close along with every other method.
+func (idx *Index) Close() error {
+	idx.cancelActiveQueries()
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.closed {
+		return nil
+	}
+
+	C.close_index(idx.handle)
+	idx.closed = true
+	return nil
+}
+
+// registerQuery records cancel so Close can force it, returning an id to
+// pass to unregisterQuery once the query is done.
+func (idx *Index) registerQuery(cancel context.CancelFunc) int {
+	idx.queriesMu.Lock()
+	defer idx.queriesMu.Unlock()
+
+	if idx.queries == nil {
+		idx.queries = make(map[int]context.CancelFunc)
+	}
+	id := idx.nextQuery
+	idx.nextQuery++
+	idx.queries[id] = cancel
+	return id
+}
+
+func (idx *Index) unregisterQuery(id int) {
+	idx.queriesMu.Lock()
+	defer idx.queriesMu.Unlock()
+	delete(idx.queries, id)
+}
+
+func (idx *Index) cancelActiveQueries() {
+	idx.queriesMu.Lock()
+	defer idx.queriesMu.Unlock()
+
+	for _, cancel := range idx.queries {
+		cancel()
+	}
+}
+
+// newCStringArray allocates a C `const char**` from paths. The returned
+// free func must be called (after the C call returns) to release it and
+// every string it points to.
+func newCStringArray(paths []string) (**C.char, func()) {
+	if len(paths) == 0 {
+		return nil, func() {}
+	}
+
+	cArray := C.malloc(C.size_t(len(paths)) * C.size_t(unsafe.Sizeof(uintptr(0))))
+	entries := (*[1 << 28]*C.char)(cArray)[:len(paths):len(paths)]
+	for i, p := range paths {
+		entries[i] = C.CString(p)
+	}
+
+	return (**C.char)(cArray), func() {
+		for _, s := range entries {
+			C.free(unsafe.Pointer(s))
+		}
+		C.free(cArray)
+	}
+}
@@ -25,3 +25,11 @@ func TestFullIndex(t *testing.T) {
 		t.Errorf("FullIndex returned %d, want: %d", result, 0)
 	}
 }
+
+func TestIndexErrorMessage(t *testing.T) {
+	err := &IndexError{Code: 42}
+	want := "indexer: operation failed with code 42"
+	if got := err.Error(); got != want {
+		t.Errorf("IndexError.Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+package indexer
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+#include "c_bindings.h"
+
+extern int goQueryRowCallback(uintptr_t token, char *columns_json, char *values_json);
+*/
+import "C"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// Row is one record from a Query result: Columns holds the returned graph
+// node/edge property names, Values their stringified values in the same
+// order.
+type Row struct {
+	Columns []string
+	Values  []string
+}
+
+type queryState struct {
+	ctx  context.Context
+	rows chan<- Row
+}
+
+// Query runs cypher against the open index and streams results back on the
+// returned channel, so a caller can start consuming the first rows of a
+// large traversal without waiting for the whole result set to materialize.
+// The channel is closed when the query completes, fails, or ctx is done.
+//
+// idx.mu is held for the lifetime of the native call, not just while it is
+// launched: it is acquired here and released by the query goroutine once
+// C.execute_repository_query returns, so UpdateFiles and Close block until
+// the query is done with idx.handle instead of racing it. A caller that
+// stops reading the returned channel without cancelling ctx would normally
+// leave the query goroutine blocked forever inside the native call, but
+// Query derives its own cancelable context and registers it with idx, so
+// Close forces it to unblock instead of relying on caller discipline.
+func (idx *Index) Query(ctx context.Context, cypher string) (<-chan Row, error) {
+	idx.mu.Lock()
+
+	if idx.closed {
+		idx.mu.Unlock()
+		return nil, fmt.Errorf("indexer: index is closed")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	queryID := idx.registerQuery(cancel)
+
+	cCypher := C.CString(cypher)
+
+	rows := make(chan Row)
+	handle := cgo.NewHandle(&queryState{ctx: ctx, rows: rows})
+
+	go func() {
+		defer idx.mu.Unlock()
+		defer idx.unregisterQuery(queryID)
+		defer cancel()
+		defer C.free(unsafe.Pointer(cCypher))
+		defer handle.Delete()
+		defer close(rows)
+
+		cResult := C.execute_repository_query(idx.handle, cCypher, C.uintptr_t(handle))
+		if cResult != 0 {
+			// The callback already streamed whatever rows it produced;
+			// nothing left to do but let the channel close signal "done".
+			return
+		}
+	}()
+
+	return rows, nil
+}
+
+//export goQueryRowCallback
+func goQueryRowCallback(token C.uintptr_t, columnsJSON, valuesJSON *C.char) C.int {
+	state := cgo.Handle(token).Value().(*queryState)
+
+	var columns, values []string
+	if err := json.Unmarshal([]byte(C.GoString(columnsJSON)), &columns); err != nil {
+		return 1 // stop: malformed row, nothing sane to deliver
+	}
+	if err := json.Unmarshal([]byte(C.GoString(valuesJSON)), &values); err != nil {
+		return 1
+	}
+
+	select {
+	case state.rows <- Row{Columns: columns, Values: values}:
+		return 0 // continue
+	case <-state.ctx.Done():
+		return 1 // stop
+	}
+}
@@ -0,0 +1,164 @@
+package libdownload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FetchOptions configures Fetch. CacheKey should uniquely identify what's
+// being downloaded (typically version-platform-digest) so resuming an
+// interrupted download of one release never collides with another.
+type FetchOptions struct {
+	Mirrors              []string
+	StagingDir           string
+	CacheKey             string
+	Token                string
+	ExpectedSize         int64
+	Algorithm            Algorithm
+	ExpectedDigest       string
+	MaxAttemptsPerMirror int // defaults to 3
+}
+
+// FetchResult is the staged, verified archive ready for extraction.
+type FetchResult struct {
+	Path string
+	Size int64
+}
+
+// Fetch downloads opts.Mirrors in order, retrying each with exponential
+// backoff on transient errors and resuming from a partial file in
+// opts.StagingDir if one exists, then verifies the result against
+// opts.ExpectedSize/ExpectedDigest before handing back the staged path.
+//
+// A mirror that serves a complete but corrupt file (wrong size or digest —
+// a MITM'd or compromised mirror, not a network blip) is not retried
+// in place: the staged file is discarded and the next mirror is tried from
+// scratch, so a bad mirror can't poison a later run's Range-resume.
+func Fetch(ctx context.Context, client *http.Client, opts FetchOptions) (*FetchResult, error) {
+	if opts.MaxAttemptsPerMirror == 0 {
+		opts.MaxAttemptsPerMirror = 3
+	}
+	if len(opts.Mirrors) == 0 {
+		return nil, fmt.Errorf("no mirrors to fetch from")
+	}
+	if err := os.MkdirAll(opts.StagingDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating staging directory %s: %w", opts.StagingDir, err)
+	}
+
+	stagingPath := filepath.Join(opts.StagingDir, opts.CacheKey+".part")
+
+	var lastErr error
+	for _, mirror := range opts.Mirrors {
+		progress := NewProgressWriter(opts.ExpectedSize)
+		err := WithBackoff(ctx, opts.MaxAttemptsPerMirror, time.Second, func(attempt int) error {
+			return resumeDownload(ctx, client, mirror, opts.Token, stagingPath, progress)
+		})
+		if err != nil {
+			lastErr = fmt.Errorf("mirror %s: %w", mirror, err)
+			continue
+		}
+
+		size, verifyErr := verifyStagedFile(stagingPath, opts)
+		if verifyErr == nil {
+			return &FetchResult{Path: stagingPath, Size: size}, nil
+		}
+		lastErr = fmt.Errorf("mirror %s: %w", mirror, verifyErr)
+
+		if rmErr := os.Remove(stagingPath); rmErr != nil && !os.IsNotExist(rmErr) {
+			return nil, fmt.Errorf("removing corrupted staged file %s: %w", stagingPath, rmErr)
+		}
+	}
+
+	return nil, fmt.Errorf("all mirrors failed: %w", lastErr)
+}
+
+func verifyStagedFile(path string, opts FetchOptions) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("opening staged file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	hr, err := NewHashingReader(f, opts.Algorithm)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := io.Copy(io.Discard, hr)
+	if err != nil {
+		return 0, fmt.Errorf("hashing staged file %s: %w", path, err)
+	}
+
+	if opts.ExpectedSize > 0 && size != opts.ExpectedSize {
+		return 0, fmt.Errorf("staged file %s is %d bytes, lockfile expects %d", path, size, opts.ExpectedSize)
+	}
+	if opts.ExpectedDigest != "" && hr.Sum() != opts.ExpectedDigest {
+		return 0, fmt.Errorf("staged file %s failed integrity check: want %s:%s, got %s:%s", path, opts.Algorithm, opts.ExpectedDigest, opts.Algorithm, hr.Sum())
+	}
+
+	return size, nil
+}
+
+// resumeDownload fetches url into stagingPath, resuming via Range if a
+// partial file is already there. It returns a retryable error for network
+// failures and 5xx responses so WithBackoff retries them, and a plain error
+// for anything else (such as a 404, which retrying the same mirror won't
+// fix), so Fetch moves straight on to the next mirror.
+func resumeDownload(ctx context.Context, client *http.Client, url, token, stagingPath string, progress *ProgressWriter) error {
+	var offset int64
+	if fi, err := os.Stat(stagingPath); err == nil {
+		offset = fi.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Retryable(fmt.Errorf("requesting %s: %w", url, err))
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// Server ignored (or doesn't support) Range: start over.
+		offset = 0
+		flags |= os.O_TRUNC
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusRequestedRangeNotSatisfiable:
+		// offset >= the full size: we already have everything.
+		return nil
+	default:
+		if resp.StatusCode >= 500 {
+			return Retryable(fmt.Errorf("%s: HTTP %d", url, resp.StatusCode))
+		}
+		return fmt.Errorf("%s: HTTP %d", url, resp.StatusCode)
+	}
+
+	out, err := os.OpenFile(stagingPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("opening staging file %s: %w", stagingPath, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, io.TeeReader(resp.Body, progress)); err != nil {
+		return Retryable(fmt.Errorf("writing %s: %w", url, err))
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package libdownload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFetchFallsBackToSecondMirror(t *testing.T) {
+	content := []byte("libindexer static archive bytes")
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+
+	badServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer badServer.Close()
+
+	goodServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer goodServer.Close()
+
+	dir := t.TempDir()
+	result, err := Fetch(context.Background(), http.DefaultClient, FetchOptions{
+		Mirrors:              []string{badServer.URL, goodServer.URL},
+		StagingDir:           dir,
+		CacheKey:             "v1-linux-amd64",
+		Algorithm:            SHA256,
+		ExpectedDigest:       digest,
+		ExpectedSize:         int64(len(content)),
+		MaxAttemptsPerMirror: 1,
+	})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if result.Size != int64(len(content)) {
+		t.Errorf("Fetch() size = %d, want %d", result.Size, len(content))
+	}
+}
+
+func TestFetchFallsBackToSecondMirrorOnDigestMismatch(t *testing.T) {
+	content := []byte("libindexer static archive bytes")
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+
+	// corruptServer fully serves a complete, but wrong, file - e.g. a
+	// compromised or MITM'd mirror, as opposed to a network/5xx failure.
+	corruptServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not the archive you are looking for"))
+	}))
+	defer corruptServer.Close()
+
+	goodServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer goodServer.Close()
+
+	dir := t.TempDir()
+	result, err := Fetch(context.Background(), http.DefaultClient, FetchOptions{
+		Mirrors:              []string{corruptServer.URL, goodServer.URL},
+		StagingDir:           dir,
+		CacheKey:             "v1-linux-amd64",
+		Algorithm:            SHA256,
+		ExpectedDigest:       digest,
+		ExpectedSize:         int64(len(content)),
+		MaxAttemptsPerMirror: 1,
+	})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if result.Size != int64(len(content)) {
+		t.Errorf("Fetch() size = %d, want %d", result.Size, len(content))
+	}
+}
+
+func TestFetchRemovesStagedFileWhenAllMirrorsFailVerification(t *testing.T) {
+	content := []byte("libindexer static archive bytes")
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+
+	corruptServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not the archive you are looking for"))
+	}))
+	defer corruptServer.Close()
+
+	dir := t.TempDir()
+	_, err := Fetch(context.Background(), http.DefaultClient, FetchOptions{
+		Mirrors:              []string{corruptServer.URL},
+		StagingDir:           dir,
+		CacheKey:             "v1-linux-amd64",
+		Algorithm:            SHA256,
+		ExpectedDigest:       digest,
+		ExpectedSize:         int64(len(content)),
+		MaxAttemptsPerMirror: 1,
+	})
+	if err == nil {
+		t.Fatal("Fetch() error = nil, want an error when every mirror fails verification")
+	}
+
+	if _, statErr := os.Stat(filepath.Join(dir, "v1-linux-amd64.part")); !os.IsNotExist(statErr) {
+		t.Errorf("staged file still present after all mirrors failed verification: %v", statErr)
+	}
+}
+
+func TestFetchResumesFromPartialFile(t *testing.T) {
+	content := []byte("libindexer static archive bytes, a bit longer this time")
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		if rng == "" {
+			w.Write(content)
+			return
+		}
+		rest := strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-")
+		start, err := strconv.Atoi(rest)
+		if err != nil {
+			t.Errorf("unexpected Range header %q: %v", rng, err)
+		}
+		w.Header().Set("Content-Range", rng)
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(content[start:])
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	partial := content[:10]
+	if err := os.WriteFile(filepath.Join(dir, "v1-linux-amd64.part"), partial, 0644); err != nil {
+		t.Fatalf("seeding partial file: %v", err)
+	}
+
+	result, err := Fetch(context.Background(), http.DefaultClient, FetchOptions{
+		Mirrors:        []string{server.URL},
+		StagingDir:     dir,
+		CacheKey:       "v1-linux-amd64",
+		Algorithm:      SHA256,
+		ExpectedDigest: digest,
+		ExpectedSize:   int64(len(content)),
+	})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if result.Size != int64(len(content)) {
+		t.Errorf("Fetch() size = %d, want %d", result.Size, len(content))
+	}
+}
@@ -0,0 +1,178 @@
+// Package libdownload holds logic shared by the libindexer downloader
+// binaries under bindings/go/cmd/ (lockfile parsing and verification,
+// mirror selection, progress reporting) so the two near-identical cmds
+// don't drift out of sync.
+package libdownload
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// Algorithm identifies the digest algorithm a lockfile entry was recorded with.
+type Algorithm string
+
+const (
+	SHA256 Algorithm = "sha256"
+	SHA384 Algorithm = "sha384"
+	SHA512 Algorithm = "sha512"
+)
+
+func (a Algorithm) newHash() (hash.Hash, error) {
+	switch a {
+	case SHA256:
+		return sha256.New(), nil
+	case SHA384:
+		return sha512.New384(), nil
+	case SHA512:
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm %q", a)
+	}
+}
+
+// FileDigest pins the digest of a single file extracted from the archive,
+// e.g. the linked static library itself.
+type FileDigest struct {
+	Path      string    `json:"path"`
+	Algorithm Algorithm `json:"algorithm"`
+	Digest    string    `json:"digest"`
+}
+
+// PlatformEntry is the lockfile record for one GOOS-GOARCH platform.
+type PlatformEntry struct {
+	Mirrors          []string     `json:"mirrors"`
+	Size             int64        `json:"size"`
+	Algorithm        Algorithm    `json:"algorithm"`
+	Digest           string       `json:"digest"`
+	ExtractedDigests []FileDigest `json:"extracted_digests,omitempty"`
+}
+
+// Lockfile mirrors the grabit-style dependency lock: for a given release
+// Version, it pins the mirror URLs, size and digest of the archive per
+// platform, so a compromised or MITM'd download fails closed instead of
+// silently linking a malicious static library into every indexer build.
+type Lockfile struct {
+	Version   string                   `json:"version"`
+	Platforms map[string]PlatformEntry `json:"platforms"`
+}
+
+// Parse decodes lockfile JSON already held in memory, e.g. data embedded
+// into a binary with go:embed.
+func Parse(data []byte) (*Lockfile, error) {
+	var lf Lockfile
+	if err := json.Unmarshal(data, &lf); err != nil {
+		return nil, fmt.Errorf("parsing lockfile: %w", err)
+	}
+	return &lf, nil
+}
+
+// Load reads and parses a lockfile from path.
+func Load(path string) (*Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading lockfile %s: %w", path, err)
+	}
+
+	lf, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("lockfile %s: %w", path, err)
+	}
+
+	return lf, nil
+}
+
+// Save writes the lockfile back to path, formatted for readable diffs.
+func (l *Lockfile) Save(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling lockfile: %w", err)
+	}
+	data = append(data, '\n')
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing lockfile %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Platform returns the entry for platform (e.g. "linux-amd64"), or an error
+// if the lockfile has no record for it.
+func (l *Lockfile) Platform(platform string) (PlatformEntry, error) {
+	entry, ok := l.Platforms[platform]
+	if !ok {
+		return PlatformEntry{}, fmt.Errorf("no lockfile entry for platform %q", platform)
+	}
+	return entry, nil
+}
+
+// VerifyDigest checks a hex-encoded digest computed by the caller against
+// the one pinned in the lockfile entry.
+func (e PlatformEntry) VerifyDigest(got string) error {
+	if got != e.Digest {
+		return fmt.Errorf("digest mismatch: want %s:%s, got %s:%s", e.Algorithm, e.Digest, e.Algorithm, got)
+	}
+	return nil
+}
+
+// HashingReader wraps an io.Reader, feeding every byte read through h so the
+// digest of a stream can be computed without buffering it in memory.
+type HashingReader struct {
+	r io.Reader
+	h hash.Hash
+}
+
+// NewHashingReader wraps r so its content is hashed with alg as it is read.
+func NewHashingReader(r io.Reader, alg Algorithm) (*HashingReader, error) {
+	h, err := alg.newHash()
+	if err != nil {
+		return nil, err
+	}
+	return &HashingReader{r: r, h: h}, nil
+}
+
+func (hr *HashingReader) Read(p []byte) (int, error) {
+	n, err := hr.r.Read(p)
+	if n > 0 {
+		hr.h.Write(p[:n])
+	}
+	return n, err
+}
+
+// Sum returns the hex-encoded digest of everything read so far.
+func (hr *HashingReader) Sum() string {
+	return hex.EncodeToString(hr.h.Sum(nil))
+}
+
+// VerifyExtractedFile re-hashes the file at path and compares it against fd.
+// Used both right after extraction and by the standalone verify subcommand.
+func VerifyExtractedFile(path string, fd FileDigest) error {
+	h, err := fd.Algorithm.newHash()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening %s for verification: %w", path, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("hashing %s: %w", path, err)
+	}
+
+	got := hex.EncodeToString(h.Sum(nil))
+	if got != fd.Digest {
+		return fmt.Errorf("digest mismatch for %s: want %s:%s, got %s:%s", path, fd.Algorithm, fd.Digest, fd.Algorithm, got)
+	}
+
+	return nil
+}
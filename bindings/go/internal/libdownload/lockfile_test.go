@@ -0,0 +1,65 @@
+package libdownload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHashingReaderMatchesVerifyExtractedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "libindexer_c_bindings.a")
+	content := []byte("pretend static archive contents")
+
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("writing fixture file: %v", err)
+	}
+
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+
+	hr, err := NewHashingReader(strings.NewReader(string(content)), SHA256)
+	if err != nil {
+		t.Fatalf("NewHashingReader: %v", err)
+	}
+	if _, err := hr.Read(make([]byte, len(content))); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if hr.Sum() != digest {
+		t.Errorf("HashingReader.Sum() = %s, want %s", hr.Sum(), digest)
+	}
+
+	fd := FileDigest{Path: "lib/libindexer_c_bindings.a", Algorithm: SHA256, Digest: digest}
+	if err := VerifyExtractedFile(path, fd); err != nil {
+		t.Errorf("VerifyExtractedFile() = %v, want nil", err)
+	}
+
+	fd.Digest = "0000000000000000000000000000000000000000000000000000000000000000"
+	if err := VerifyExtractedFile(path, fd); err == nil {
+		t.Error("VerifyExtractedFile() with tampered digest = nil, want mismatch error")
+	}
+}
+
+func TestLockfilePlatformUnknown(t *testing.T) {
+	lf := &Lockfile{Version: "0.9.0", Platforms: map[string]PlatformEntry{
+		"linux-amd64": {Digest: "deadbeef", Algorithm: SHA256},
+	}}
+
+	if _, err := lf.Platform("windows-amd64"); err == nil {
+		t.Error("Platform(\"windows-amd64\") = nil error, want error for missing platform")
+	}
+
+	entry, err := lf.Platform("linux-amd64")
+	if err != nil {
+		t.Fatalf("Platform(\"linux-amd64\") returned error: %v", err)
+	}
+	if err := entry.VerifyDigest("deadbeef"); err != nil {
+		t.Errorf("VerifyDigest() = %v, want nil", err)
+	}
+	if err := entry.VerifyDigest("wrong"); err == nil {
+		t.Error("VerifyDigest(\"wrong\") = nil, want mismatch error")
+	}
+}
@@ -0,0 +1,57 @@
+package libdownload
+
+import (
+	"log/slog"
+	"time"
+)
+
+// ProgressWriter is an io.Writer suitable for io.TeeReader that logs
+// structured throughput events every progressBytes bytes or
+// progressInterval, whichever comes first, instead of on every chunk.
+type ProgressWriter struct {
+	total int64
+
+	n          int64
+	lastLogged int64
+	lastLogAt  time.Time
+	startedAt  time.Time
+}
+
+const (
+	progressBytes    = 8 * 1024 * 1024
+	progressInterval = 2 * time.Second
+)
+
+// NewProgressWriter reports progress against an expected total size. Pass 0
+// if the total is unknown; progress is still logged, just without
+// total_bytes and percent.
+func NewProgressWriter(total int64) *ProgressWriter {
+	now := time.Now()
+	return &ProgressWriter{total: total, startedAt: now, lastLogAt: now}
+}
+
+func (p *ProgressWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	p.n += int64(n)
+
+	now := time.Now()
+	if p.n-p.lastLogged < progressBytes && now.Sub(p.lastLogAt) < progressInterval {
+		return n, nil
+	}
+
+	elapsed := now.Sub(p.startedAt).Seconds()
+	if elapsed <= 0 {
+		elapsed = 0.001
+	}
+	throughputMiBps := float64(p.n) / elapsed / (1024 * 1024)
+
+	attrs := []any{"bytes", p.n, "throughput_mib_s", throughputMiBps}
+	if p.total > 0 {
+		attrs = append(attrs, "total_bytes", p.total, "percent", float64(p.n)*100/float64(p.total))
+	}
+	slog.Info("Download progress", attrs...)
+
+	p.lastLogged = p.n
+	p.lastLogAt = now
+	return n, nil
+}
@@ -0,0 +1,62 @@
+package libdownload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// retryableError marks an error as transient (a 5xx response or a network
+// failure) so WithBackoff knows to retry instead of giving up immediately,
+// e.g. on a 404 or a digest mismatch.
+type retryableError struct{ err error }
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// Retryable marks err as transient. Callers outside this package (e.g. the
+// zstd:chunked Range-request path) use it to plug their own transport
+// errors into WithBackoff the same way Fetch does.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err}
+}
+
+// IsRetryable reports whether err (or something it wraps) was marked via
+// Retryable.
+func IsRetryable(err error) bool {
+	var re *retryableError
+	return errors.As(err, &re)
+}
+
+// WithBackoff calls fn up to maxAttempts times, waiting base*2^attempt
+// between retryable failures. A non-retryable error returns immediately.
+func WithBackoff(ctx context.Context, maxAttempts int, base time.Duration, fn func(attempt int) error) error {
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		err = fn(attempt)
+		if err == nil {
+			return nil
+		}
+		if !IsRetryable(err) {
+			return err
+		}
+		if attempt == maxAttempts-1 {
+			break
+		}
+
+		wait := base * time.Duration(uint(1)<<uint(attempt))
+		slog.Warn("Retrying after transient error", "attempt", attempt+1, "max_attempts", maxAttempts, "wait", wait, "error", err)
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return fmt.Errorf("exhausted %d attempts: %w", maxAttempts, err)
+}
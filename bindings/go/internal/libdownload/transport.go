@@ -0,0 +1,39 @@
+package libdownload
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// NewTransport builds an http.Transport that honors the usual proxy env
+// vars (HTTPS_PROXY/HTTP_PROXY/NO_PROXY, via http.ProxyFromEnvironment) plus
+// LIBINDEXER_CA_BUNDLE, a PEM file corporate users can set to trust a
+// custom/self-signed CA used by an internal mirror or TLS-inspecting proxy.
+func NewTransport() (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+
+	caBundle := os.Getenv("LIBINDEXER_CA_BUNDLE")
+	if caBundle == "" {
+		return transport, nil
+	}
+
+	pem, err := os.ReadFile(caBundle)
+	if err != nil {
+		return nil, fmt.Errorf("reading LIBINDEXER_CA_BUNDLE %s: %w", caBundle, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in LIBINDEXER_CA_BUNDLE %s", caBundle)
+	}
+
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return transport, nil
+}